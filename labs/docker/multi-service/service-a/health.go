@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Checker is a single dependency or internal condition that gates
+// readiness. Check should respect ctx's deadline and return a descriptive
+// error on failure.
+type Checker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+// checkResult is the JSON shape reported for each Checker in /readyz.
+type checkResult struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Latency string `json:"latency"`
+	Error   string `json:"error,omitempty"`
+}
+
+// shutdownChecker fails as soon as the process starts shutting down, so
+// readiness flips before the listener is actually closed and Kubernetes
+// stops routing new traffic in time.
+type shutdownChecker struct {
+	shuttingDown *atomic.Bool
+}
+
+func (c *shutdownChecker) Name() string { return "shutting-down" }
+
+func (c *shutdownChecker) Check(ctx context.Context) error {
+	if c.shuttingDown.Load() {
+		return errors.New("server is shutting down")
+	}
+	return nil
+}
+
+// handleLivez reports whether the process itself is alive: 200 while it
+// runs, and 503 once shutdown has begun. Readiness is reported separately
+// by handleReadyz, whose shutdownChecker fails from the same moment.
+func (s *Server) handleLivez(w http.ResponseWriter, r *http.Request) {
+	if s.shuttingDown.Load() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"status": "shutting-down"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "alive"})
+}
+
+// handleReadyz runs every registered Checker and returns 503 if any of them
+// failed, with per-check name, status, latency, and error in the body.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	results := make([]checkResult, len(s.checkers))
+	allOK := true
+
+	for i, checker := range s.checkers {
+		start := time.Now()
+		err := checker.Check(ctx)
+		results[i] = checkResult{
+			Name:    checker.Name(),
+			Status:  "ok",
+			Latency: time.Since(start).String(),
+		}
+		if err != nil {
+			results[i].Status = "failed"
+			results[i].Error = err.Error()
+			allOK = false
+		}
+	}
+
+	status := http.StatusOK
+	if !allOK {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"status": map[bool]string{true: "ready", false: "not-ready"}[allOK],
+		"checks": results,
+	})
+}
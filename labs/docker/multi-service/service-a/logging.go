@@ -0,0 +1,29 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// newLogWriter returns the writer the JSON log handler should write to,
+// plus an io.Closer to flush and close it on shutdown (nil if there's
+// nothing to close). When config.LogFile is set, logs are teed to stdout
+// and a size/age/backup-rotated file, which is the common shape for
+// on-host deployments without a log collector attached to stdout.
+func newLogWriter(config *Config) (io.Writer, io.Closer) {
+	if config.LogFile == "" {
+		return os.Stdout, nil
+	}
+
+	rotator := &lumberjack.Logger{
+		Filename:   config.LogFile,
+		MaxSize:    config.LogMaxSizeMB,
+		MaxBackups: config.LogMaxBackups,
+		MaxAge:     config.LogMaxAgeDays,
+		Compress:   config.LogCompress,
+	}
+
+	return io.MultiWriter(os.Stdout, rotator), rotator
+}
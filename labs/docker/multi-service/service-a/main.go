@@ -2,23 +2,50 @@ package main
 
 import (
 	"context"
-	"crypto/subtle"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// ctxKey is a private type for context keys set by this service, so that
+// values don't collide with keys set by other packages using the same
+// context.
+type ctxKey string
+
+const requestIDKey ctxKey = "request_id"
+
 type Config struct {
-	Port     string
-	LogLevel slog.Level
-	AuthKey  string
+	Port            string
+	LogLevel        slog.Level
+	AuthSpec        string
+	OTLPEndpoint    string
+	OTELServiceName string
+
+	LogFile       string
+	LogMaxSizeMB  int
+	LogMaxBackups int
+	LogMaxAgeDays int
+	LogCompress   bool
 }
 
 type Response struct {
@@ -28,93 +55,158 @@ type Response struct {
 }
 
 type Server struct {
-	config *Config
-	logger *slog.Logger
-	server *http.Server
+	config        *Config
+	logger        *slog.Logger
+	server        *http.Server
+	tracer        trace.Tracer
+	auth          Auth
+	metrics       *Metrics
+	logSinkCloser io.Closer
+	checkers      []Checker
+	shuttingDown  *atomic.Bool
 }
 
-func NewServer(config *Config) *Server {
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+func NewServer(config *Config) (*Server, error) {
+	logWriter, logSinkCloser := newLogWriter(config)
+	logger := slog.New(slog.NewJSONHandler(logWriter, &slog.HandlerOptions{
 		Level: config.LogLevel,
 	}))
 
+	auth, err := NewAuth(config.AuthSpec)
+	if err != nil {
+		return nil, fmt.Errorf("building auth backend: %w", err)
+	}
+
 	mux := http.NewServeMux()
 	srv := &http.Server{
 		Addr:         ":" + config.Port,
-		Handler:      mux,
+		Handler:      otelhttp.NewHandler(mux, "service-a"),
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  15 * time.Second,
 	}
 
+	shuttingDown := &atomic.Bool{}
+
 	s := &Server{
-		config: config,
-		logger: logger,
-		server: srv,
+		config:        config,
+		logger:        logger,
+		server:        srv,
+		tracer:        otel.Tracer("service-a"),
+		auth:          auth,
+		metrics:       NewMetrics(),
+		logSinkCloser: logSinkCloser,
+		checkers:      []Checker{&shutdownChecker{shuttingDown: shuttingDown}},
+		shuttingDown:  shuttingDown,
+	}
+
+	mux.HandleFunc("/greet", s.metrics.Wrap("service-a", "/greet", s.authMiddleware(s.handleGreet)))
+	mux.HandleFunc("/livez", s.metrics.Wrap("service-a", "/livez", s.handleLivez))
+	mux.HandleFunc("/readyz", s.metrics.Wrap("service-a", "/readyz", s.handleReadyz))
+	mux.Handle("/metrics", promhttp.Handler())
+
+	return s, nil
+}
+
+// initTracer wires up an OTLP/HTTP exporter and installs it as the global
+// TracerProvider. The returned shutdown func must be called before the
+// process exits so that buffered spans are flushed.
+func initTracer(ctx context.Context, config *Config) (func(context.Context) error, error) {
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(config.OTLPEndpoint))
+	if err != nil {
+		return nil, err
 	}
 
-	mux.HandleFunc("/greet", s.authMiddleware(s.handleGreet))
-	mux.HandleFunc("/health", s.handleHealth)
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName(config.OTELServiceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
 
-	return s
+	return tp.Shutdown, nil
 }
 
 func (s *Server) authMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		requestID := uuid.New().String()
-		ctx := context.WithValue(r.Context(), "request_id", requestID)
+		ctx := context.WithValue(r.Context(), requestIDKey, requestID)
 		r = r.WithContext(ctx)
 
-		authKey := r.Header.Get("X-Auth-Key")
 		clientIP := r.RemoteAddr
 
 		// Log authentication attempt
-		s.logger.Info("Authentication attempt",
+		s.logger.With(s.traceAttrs(r.Context())...).Info("Authentication attempt",
 			slog.String("request_id", requestID),
 			slog.String("client_ip", clientIP),
 			slog.String("method", r.Method),
 			slog.String("path", r.URL.Path),
 		)
 
-		if subtle.ConstantTimeCompare([]byte(authKey), []byte(s.config.AuthKey)) != 1 {
-			// Enhanced unauthorized access logging
-			s.logger.Warn("Authentication failed",
+		principal, ok := s.auth.Validate(r)
+		if !ok {
+			// Enhanced unauthorized access logging. A failed attempt has no
+			// resolved principal, so none is logged; the raw credential is
+			// never logged either.
+			s.logger.With(s.traceAttrs(r.Context())...).Warn("Authentication failed",
 				slog.String("request_id", requestID),
 				slog.String("client_ip", clientIP),
 				slog.String("method", r.Method),
 				slog.String("path", r.URL.Path),
-				slog.String("auth_key_provided", maskAuthKey(authKey)),
 			)
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		// Log successful authentication
-		s.logger.Info("Authentication successful",
+		s.logger.With(s.traceAttrs(r.Context())...).Info("Authentication successful",
 			slog.String("request_id", requestID),
 			slog.String("client_ip", clientIP),
 			slog.String("method", r.Method),
 			slog.String("path", r.URL.Path),
+			slog.String("principal", principal),
 		)
 
 		next.ServeHTTP(w, r)
 	}
 }
 
-// maskAuthKey masks the authentication key for logging
-func maskAuthKey(key string) string {
-	if len(key) > 4 {
-		return key[:2] + "****" + key[len(key)-2:]
+// traceAttrs returns slog attributes for the trace_id/span_id of the span
+// active in ctx, if any, so every log record can be correlated with a trace.
+func (s *Server) traceAttrs(ctx context.Context) []any {
+	span := trace.SpanContextFromContext(ctx)
+	if !span.IsValid() {
+		return nil
+	}
+	return []any{
+		slog.String("trace_id", span.TraceID().String()),
+		slog.String("span_id", span.SpanID().String()),
 	}
-	return "****"
 }
 
 func (s *Server) handleGreet(w http.ResponseWriter, r *http.Request) {
-	requestID := r.Context().Value("request_id").(string)
+	requestID := r.Context().Value(requestIDKey).(string)
 	clientIP := r.RemoteAddr
 
+	// Honor an inbound request ID from the caller (e.g. Service B) instead of
+	// always minting a fresh one, so logs correlate across services.
+	if inbound := r.Header.Get("X-Request-ID"); inbound != "" {
+		requestID = inbound
+	}
+
 	// Log incoming request details
-	s.logger.Info("Processing greeting request",
+	s.logger.With(s.traceAttrs(r.Context())...).Info("Processing greeting request",
 		slog.String("request_id", requestID),
 		slog.String("client_ip", clientIP),
 		slog.String("method", r.Method),
@@ -134,7 +226,7 @@ func (s *Server) handleGreet(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		// Log encoding error with detailed context
-		s.logger.Error("Failed to encode response",
+		s.logger.With(s.traceAttrs(r.Context())...).Error("Failed to encode response",
 			slog.String("error", err.Error()),
 			slog.String("request_id", requestID),
 			slog.String("client_ip", clientIP),
@@ -145,7 +237,7 @@ func (s *Server) handleGreet(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Log successful response
-	s.logger.Info("Greeting request processed successfully",
+	s.logger.With(s.traceAttrs(r.Context())...).Info("Greeting request processed successfully",
 		slog.String("request_id", requestID),
 		slog.String("client_ip", clientIP),
 		slog.Duration("processing_time", time.Since(startTime)),
@@ -153,43 +245,14 @@ func (s *Server) handleGreet(w http.ResponseWriter, r *http.Request) {
 	)
 }
 
-func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
-	requestID := uuid.New().String()
-	startTime := time.Now()
-
-	// Log health check request
-	s.logger.Info("Health check received",
-		slog.String("request_id", requestID),
-		slog.String("client_ip", r.RemoteAddr),
-		slog.String("method", r.Method),
-		slog.String("path", r.URL.Path),
-	)
-
-	// Perform basic health checks
-	status := map[string]string{
-		"status":      "healthy",
-		"timestamp":   time.Now().UTC().Format(time.RFC3339),
-		"request_id":  requestID,
-		"server_port": s.config.Port,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(status)
-
-	// Log health check response
-	s.logger.Info("Health check completed",
-		slog.String("request_id", requestID),
-		slog.Duration("processing_time", time.Since(startTime)),
-	)
-}
-
 func (s *Server) Start() error {
 	// Log server start with configuration details
 	s.logger.Info("Initializing server",
 		slog.String("port", s.config.Port),
 		slog.String("log_level", s.config.LogLevel.String()),
-		slog.Bool("auth_configured", s.config.AuthKey != ""),
+		slog.String("auth_spec_scheme", authScheme(s.config.AuthSpec)),
+		slog.String("otlp_endpoint", s.config.OTLPEndpoint),
+		slog.Bool("log_file_configured", s.config.LogFile != ""),
 	)
 
 	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
@@ -205,7 +268,7 @@ func (s *Server) Start() error {
 func (s *Server) Shutdown(ctx context.Context) error {
 	// Log graceful shutdown initiation
 	s.logger.Info("Initiating graceful shutdown",
-		slog.String("timeout", ctx.Value("timeout").(string)),
+		slog.String("timeout", ctx.Value(shutdownTimeoutKey).(string)),
 	)
 
 	if err := s.server.Shutdown(ctx); err != nil {
@@ -217,14 +280,47 @@ func (s *Server) Shutdown(ctx context.Context) error {
 	}
 
 	s.logger.Info("Server shutdown completed successfully")
+
+	// Close the rotating log file last so this message and everything
+	// before it are flushed to disk.
+	if s.logSinkCloser != nil {
+		if err := s.logSinkCloser.Close(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+const shutdownTimeoutKey ctxKey = "timeout"
+
+// envInt reads an integer environment variable, falling back to def if it
+// is unset or unparsable.
+func envInt(key string, def int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// envBool reads a boolean environment variable, falling back to def if it
+// is unset or unparsable.
+func envBool(key string, def bool) bool {
+	v, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
 func main() {
 	config := &Config{
-		Port:     os.Getenv("PORT"),
-		LogLevel: slog.LevelInfo,
-		AuthKey:  os.Getenv("AUTH_KEY"),
+		Port:            os.Getenv("PORT"),
+		LogLevel:        slog.LevelInfo,
+		AuthSpec:        os.Getenv("AUTH_SPEC"),
+		OTLPEndpoint:    os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"),
+		OTELServiceName: "service-a",
 	}
 
 	if config.Port == "" {
@@ -235,15 +331,38 @@ func main() {
 		)
 	}
 
-	if config.AuthKey == "" {
-		config.AuthKey = "default-secret-key"
-		// Log security warning about default auth key
-		slog.Warn("No authentication key provided, using default. This is NOT recommended for production!",
-			slog.String("default_key", maskAuthKey(config.AuthKey)),
+	if config.AuthSpec == "" {
+		config.AuthSpec = "static://default-secret-key"
+		// Log security warning about default auth backend
+		slog.Warn("No auth spec provided, using default static backend. This is NOT recommended for production!",
+			slog.String("auth_scheme", authScheme(config.AuthSpec)),
 		)
 	}
 
-	server := NewServer(config)
+	if config.OTLPEndpoint == "" {
+		config.OTLPEndpoint = "http://localhost:4318"
+		slog.Info("No OTLP endpoint specified, using default",
+			slog.String("default_otlp_endpoint", config.OTLPEndpoint),
+		)
+	}
+
+	config.LogFile = os.Getenv("LOG_FILE")
+	config.LogMaxSizeMB = envInt("LOG_MAX_SIZE_MB", 100)
+	config.LogMaxBackups = envInt("LOG_MAX_BACKUPS", 3)
+	config.LogMaxAgeDays = envInt("LOG_MAX_AGE_DAYS", 28)
+	config.LogCompress = envBool("LOG_COMPRESS", false)
+
+	shutdownTracer, err := initTracer(context.Background(), config)
+	if err != nil {
+		slog.Error("Failed to initialize tracing", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	server, err := NewServer(config)
+	if err != nil {
+		slog.Error("Failed to initialize server", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
 
 	// Graceful shutdown
 	stop := make(chan os.Signal, 1)
@@ -261,9 +380,13 @@ func main() {
 
 	<-stop
 
+	// Flip readiness to failing immediately so Kubernetes stops routing new
+	// traffic before the listener is actually closed below.
+	server.shuttingDown.Store(true)
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	ctx = context.WithValue(ctx, "timeout", "10s")
+	ctx = context.WithValue(ctx, shutdownTimeoutKey, "10s")
 
 	if err := server.Shutdown(ctx); err != nil {
 		server.logger.Error("Server shutdown error",
@@ -272,5 +395,9 @@ func main() {
 		)
 	}
 
+	if err := shutdownTracer(ctx); err != nil {
+		server.logger.Error("Tracer shutdown error", slog.String("error", err.Error()))
+	}
+
 	server.logger.Info("Service A shutdown complete")
 }
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Credential resolves the outbound X-Auth-Key sent to Service A. Keeping it
+// behind an interface lets operators pick a static value or a file that can
+// be rotated without restarting the process, the same way Service A's
+// inbound Auth backends are selected.
+type Credential interface {
+	Value() (string, error)
+}
+
+// NewCredential builds a Credential from a URI-style spec, e.g.:
+//
+//	static://some-shared-secret
+//	file:///etc/service-b/service-a.key
+//
+// The static secret is taken verbatim from everything after "static://"
+// rather than parsed as a URI host, since net/url would silently
+// reinterpret or truncate any secret containing '/', '@', ':', '?', or '#'
+// (all valid in, e.g., a base64 secret) instead of erroring.
+func NewCredential(spec string) (Credential, error) {
+	scheme, rest, ok := strings.Cut(spec, "://")
+	if !ok {
+		return nil, fmt.Errorf("credential spec %q is missing a scheme", spec)
+	}
+
+	switch scheme {
+	case "static":
+		if rest == "" {
+			return nil, fmt.Errorf("static credential spec %q is missing a key", spec)
+		}
+		return staticCredential(rest), nil
+	case "file":
+		if rest == "" {
+			return nil, fmt.Errorf("file credential spec %q is missing a path", spec)
+		}
+		return &fileCredential{path: rest}, nil
+	default:
+		return nil, fmt.Errorf("unknown credential scheme %q", scheme)
+	}
+}
+
+// credentialScheme extracts just the scheme from a credential spec, so
+// startup logs can report which backend is active without leaking the
+// secret or file path embedded in the spec itself.
+func credentialScheme(spec string) string {
+	scheme, _, ok := strings.Cut(spec, "://")
+	if !ok {
+		return "unknown"
+	}
+	return scheme
+}
+
+type staticCredential string
+
+func (c staticCredential) Value() (string, error) {
+	return string(c), nil
+}
+
+// fileCredential re-reads its file on every call, so an operator can rotate
+// the Service A credential by editing the file in place.
+type fileCredential struct {
+	path string
+}
+
+func (c *fileCredential) Value() (string, error) {
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return "", fmt.Errorf("reading credential file: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
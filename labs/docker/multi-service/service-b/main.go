@@ -10,17 +10,59 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ctxKey is a private type for context keys set by this service, so that
+// values don't collide with keys set by other packages using the same
+// context.
+type ctxKey string
+
+const (
+	requestIDKey       ctxKey = "request_id"
+	shutdownTimeoutKey ctxKey = "timeout"
 )
 
 type Config struct {
-	Port            string
-	LogLevel        slog.Level
-	ServiceAURL     string
-	ServiceAAuthKey string
+	Port                   string
+	LogLevel               slog.Level
+	ServiceAURL            string
+	ServiceACredentialSpec string
+	OTLPEndpoint           string
+	OTELServiceName        string
+
+	RetryMax              int
+	BreakerThreshold      float64
+	BreakerOpenDuration   time.Duration
+	BreakerHalfOpenProbes int
+	BulkheadSize          int
+
+	// ProcessTimeout bounds the resilience.Do call in handleProcess (the
+	// bulkhead wait plus every retry attempt and backoff). It must stay
+	// comfortably under the server's WriteTimeout so a genuinely-down
+	// Service A still gets a clean error response from us instead of the
+	// listener aborting the response mid-write.
+	ProcessTimeout time.Duration
+
+	LogFile       string
+	LogMaxSizeMB  int
+	LogMaxBackups int
+	LogMaxAgeDays int
+	LogCompress   bool
 }
 
 type ServiceAResponse struct {
@@ -37,48 +79,130 @@ type Response struct {
 }
 
 type Server struct {
-	config *Config
-	logger *slog.Logger
-	server *http.Server
-	client *http.Client
+	config        *Config
+	logger        *slog.Logger
+	server        *http.Server
+	client        *http.Client
+	tracer        trace.Tracer
+	credential    Credential
+	resilience    *Resilience
+	metrics       *Metrics
+	logSinkCloser io.Closer
+	checkers      []Checker
+	shuttingDown  *atomic.Bool
 }
 
-func NewServer(config *Config) *Server {
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+func NewServer(config *Config) (*Server, error) {
+	logWriter, logSinkCloser := newLogWriter(config)
+	logger := slog.New(slog.NewJSONHandler(logWriter, &slog.HandlerOptions{
 		Level: config.LogLevel,
 	}))
 
+	credential, err := NewCredential(config.ServiceACredentialSpec)
+	if err != nil {
+		return nil, fmt.Errorf("building Service A credential: %w", err)
+	}
+
 	mux := http.NewServeMux()
 	srv := &http.Server{
-		Addr:         ":" + config.Port,
-		Handler:      mux,
+		Addr:    ":" + config.Port,
+		Handler: otelhttp.NewHandler(mux, "service-b"),
+		// WriteTimeout must stay comfortably above config.ProcessTimeout
+		// (default 8s vs. 10s here), since handleProcess needs to finish
+		// writing its response after resilience.Do gives up.
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  15 * time.Second,
 	}
 
+	client := &http.Client{
+		Timeout:   5 * time.Second,
+		Transport: otelhttp.NewTransport(http.DefaultTransport),
+	}
+
+	metrics := NewMetrics()
+	shuttingDown := &atomic.Bool{}
+
 	s := &Server{
-		config: config,
-		logger: logger,
-		server: srv,
-		client: &http.Client{
-			Timeout: 5 * time.Second,
+		config:     config,
+		logger:     logger,
+		server:     srv,
+		client:     client,
+		tracer:     otel.Tracer("service-b"),
+		credential: credential,
+		resilience: NewResilience(client, logger, metrics, config),
+		metrics:    metrics,
+		checkers: []Checker{
+			&shutdownChecker{shuttingDown: shuttingDown},
+			&httpGetChecker{
+				name:   "service_a",
+				url:    config.ServiceAURL + "/livez",
+				client: &http.Client{Timeout: 2 * time.Second},
+			},
 		},
+		shuttingDown:  shuttingDown,
+		logSinkCloser: logSinkCloser,
+	}
+
+	mux.HandleFunc("/process", s.metrics.Wrap("service-b", "/process", s.handleProcess))
+	mux.HandleFunc("/livez", s.metrics.Wrap("service-b", "/livez", s.handleLivez))
+	mux.HandleFunc("/readyz", s.metrics.Wrap("service-b", "/readyz", s.handleReadyz))
+	mux.Handle("/metrics", promhttp.Handler())
+
+	return s, nil
+}
+
+// initTracer wires up an OTLP/HTTP exporter and installs it as the global
+// TracerProvider. The returned shutdown func must be called before the
+// process exits so that buffered spans are flushed.
+func initTracer(ctx context.Context, config *Config) (func(context.Context) error, error) {
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(config.OTLPEndpoint))
+	if err != nil {
+		return nil, err
 	}
 
-	mux.HandleFunc("/process", s.handleProcess)
-	mux.HandleFunc("/health", s.handleHealth)
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName(config.OTELServiceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
 
-	return s
+	return tp.Shutdown, nil
+}
+
+// traceAttrs returns slog attributes for the trace_id/span_id of the span
+// active in ctx, if any, so every log record can be correlated with a trace.
+func (s *Server) traceAttrs(ctx context.Context) []any {
+	span := trace.SpanContextFromContext(ctx)
+	if !span.IsValid() {
+		return nil
+	}
+	return []any{
+		slog.String("trace_id", span.TraceID().String()),
+		slog.String("span_id", span.SpanID().String()),
+	}
 }
 
 func (s *Server) handleProcess(w http.ResponseWriter, r *http.Request) {
 	requestID := uuid.New().String()
-	ctx := context.WithValue(r.Context(), "request_id", requestID)
+	ctx := context.WithValue(r.Context(), requestIDKey, requestID)
+	r = r.WithContext(ctx)
 	clientIP := r.RemoteAddr
 
 	// Log incoming request details
-	s.logger.Info("Processing service interaction request",
+	s.logger.With(s.traceAttrs(ctx)...).Info("Processing service interaction request",
 		slog.String("request_id", requestID),
 		slog.String("client_ip", clientIP),
 		slog.String("method", r.Method),
@@ -87,31 +211,50 @@ func (s *Server) handleProcess(w http.ResponseWriter, r *http.Request) {
 
 	startTime := time.Now()
 
-	// Call Service A
+	// Bound the whole resilience call (bulkhead wait plus every retry
+	// attempt and backoff) well inside the server's WriteTimeout, so a
+	// genuinely-down Service A still gets a clean response from us instead
+	// of the listener aborting the response mid-write.
+	ctx, cancel := context.WithTimeout(ctx, s.config.ProcessTimeout)
+	defer cancel()
+
+	// Call Service A. The otelhttp transport injects the traceparent header
+	// itself, so the only thing left to propagate manually is our own
+	// request_id for log correlation.
 	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/greet", s.config.ServiceAURL), nil)
 	if err != nil {
 		s.handleError(w, r, err, "Failed to create request to Service A", http.StatusInternalServerError)
 		return
 	}
-	req.Header.Set("X-Auth-Key", s.config.ServiceAAuthKey)
+
+	credential, err := s.credential.Value()
+	if err != nil {
+		s.handleError(w, r, err, "Failed to resolve Service A credential", http.StatusInternalServerError)
+		return
+	}
+	req.Header.Set("X-Auth-Key", credential)
 	req.Header.Set("X-Request-ID", requestID)
 
 	// Log outgoing request to Service A
-	s.logger.Info("Calling Service A",
+	s.logger.With(s.traceAttrs(ctx)...).Info("Calling Service A",
 		slog.String("request_id", requestID),
 		slog.String("service_a_url", s.config.ServiceAURL),
-		slog.String("auth_key_masked", maskAuthKey(s.config.ServiceAAuthKey)),
+		slog.String("credential_scheme", credentialScheme(s.config.ServiceACredentialSpec)),
 	)
 
-	resp, err := s.client.Do(req)
+	resp, err := s.resilience.Do(req)
 	if err != nil {
+		if errors.Is(err, ErrCircuitOpen) {
+			s.handleError(w, r, err, "Service A circuit breaker is open", http.StatusServiceUnavailable)
+			return
+		}
 		s.handleError(w, r, err, "Failed to call Service A", http.StatusServiceUnavailable)
 		return
 	}
 	defer resp.Body.Close()
 
 	// Log Service A response details
-	s.logger.Info("Received response from Service A",
+	s.logger.With(s.traceAttrs(ctx)...).Info("Received response from Service A",
 		slog.String("request_id", requestID),
 		slog.Int("status_code", resp.StatusCode),
 	)
@@ -150,7 +293,7 @@ func (s *Server) handleProcess(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 
 	// Log response preparation
-	s.logger.Info("Preparing response",
+	s.logger.With(s.traceAttrs(ctx)...).Info("Preparing response",
 		slog.String("request_id", requestID),
 		slog.String("service_a_message", serviceAResp.Message),
 		slog.String("service_b_message", response.ServiceBMessage),
@@ -158,7 +301,7 @@ func (s *Server) handleProcess(w http.ResponseWriter, r *http.Request) {
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		// Log encoding error with detailed context
-		s.logger.Error("Failed to encode response",
+		s.logger.With(s.traceAttrs(ctx)...).Error("Failed to encode response",
 			slog.String("error", err.Error()),
 			slog.String("request_id", requestID),
 			slog.String("client_ip", clientIP),
@@ -169,7 +312,7 @@ func (s *Server) handleProcess(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Log successful response
-	s.logger.Info("Process request completed successfully",
+	s.logger.With(s.traceAttrs(ctx)...).Info("Process request completed successfully",
 		slog.String("request_id", requestID),
 		slog.String("client_ip", clientIP),
 		slog.String("service_a_request_id", serviceAResp.RequestID),
@@ -178,11 +321,11 @@ func (s *Server) handleProcess(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleError(w http.ResponseWriter, r *http.Request, err error, logMessage string, statusCode int) {
-	requestID := r.Context().Value("request_id").(string)
+	requestID := r.Context().Value(requestIDKey).(string)
 	clientIP := r.RemoteAddr
 
 	// Enhanced error logging with more context
-	s.logger.Error(logMessage,
+	s.logger.With(s.traceAttrs(r.Context())...).Error(logMessage,
 		slog.String("error", err.Error()),
 		slog.String("request_id", requestID),
 		slog.String("client_ip", clientIP),
@@ -194,44 +337,20 @@ func (s *Server) handleError(w http.ResponseWriter, r *http.Request, err error,
 	http.Error(w, logMessage, statusCode)
 }
 
-func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
-	requestID := uuid.New().String()
-	startTime := time.Now()
-
-	// Log health check request
-	s.logger.Info("Health check received",
-		slog.String("request_id", requestID),
-		slog.String("client_ip", r.RemoteAddr),
-		slog.String("method", r.Method),
-		slog.String("path", r.URL.Path),
-	)
-
-	// Perform basic health checks
-	status := map[string]string{
-		"status":      "healthy",
-		"timestamp":   time.Now().UTC().Format(time.RFC3339),
-		"request_id":  requestID,
-		"server_port": s.config.Port,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(status)
-
-	// Log health check response
-	s.logger.Info("Health check completed",
-		slog.String("request_id", requestID),
-		slog.Duration("processing_time", time.Since(startTime)),
-	)
-}
-
 func (s *Server) Start() error {
 	// Log server start with configuration details
 	s.logger.Info("Initializing server",
 		slog.String("port", s.config.Port),
 		slog.String("log_level", s.config.LogLevel.String()),
 		slog.String("service_a_url", s.config.ServiceAURL),
-		slog.Bool("service_a_auth_configured", s.config.ServiceAAuthKey != ""),
+		slog.String("credential_scheme", credentialScheme(s.config.ServiceACredentialSpec)),
+		slog.String("otlp_endpoint", s.config.OTLPEndpoint),
+		slog.Int("retry_max", s.config.RetryMax),
+		slog.Float64("breaker_threshold", s.config.BreakerThreshold),
+		slog.Duration("breaker_open_duration", s.config.BreakerOpenDuration),
+		slog.Int("breaker_half_open_probes", s.config.BreakerHalfOpenProbes),
+		slog.Int("bulkhead_size", s.config.BulkheadSize),
+		slog.Bool("log_file_configured", s.config.LogFile != ""),
 	)
 
 	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
@@ -247,7 +366,7 @@ func (s *Server) Start() error {
 func (s *Server) Shutdown(ctx context.Context) error {
 	// Log graceful shutdown initiation
 	s.logger.Info("Initiating graceful shutdown",
-		slog.String("timeout", ctx.Value("timeout").(string)),
+		slog.String("timeout", ctx.Value(shutdownTimeoutKey).(string)),
 	)
 
 	if err := s.server.Shutdown(ctx); err != nil {
@@ -259,23 +378,66 @@ func (s *Server) Shutdown(ctx context.Context) error {
 	}
 
 	s.logger.Info("Server shutdown completed successfully")
+
+	// Close the rotating log file last so this message and everything
+	// before it are flushed to disk.
+	if s.logSinkCloser != nil {
+		if err := s.logSinkCloser.Close(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-// maskAuthKey masks the authentication key for logging
-func maskAuthKey(key string) string {
-	if len(key) > 4 {
-		return key[:2] + "****" + key[len(key)-2:]
+// envInt reads an integer environment variable, falling back to def if it
+// is unset or unparsable.
+func envInt(key string, def int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// envFloat reads a float environment variable, falling back to def if it is
+// unset or unparsable.
+func envFloat(key string, def float64) float64 {
+	v, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return def
 	}
-	return "****"
+	return v
+}
+
+// envDuration reads a duration environment variable (e.g. "30s"), falling
+// back to def if it is unset or unparsable.
+func envDuration(key string, def time.Duration) time.Duration {
+	v, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// envBool reads a boolean environment variable, falling back to def if it
+// is unset or unparsable.
+func envBool(key string, def bool) bool {
+	v, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return v
 }
 
 func main() {
 	config := &Config{
-		Port:            os.Getenv("PORT"),
-		LogLevel:        slog.LevelInfo,
-		ServiceAURL:     os.Getenv("SERVICE_A_URL"),
-		ServiceAAuthKey: os.Getenv("SERVICE_A_AUTH_KEY"),
+		Port:                   os.Getenv("PORT"),
+		LogLevel:               slog.LevelInfo,
+		ServiceAURL:            os.Getenv("SERVICE_A_URL"),
+		ServiceACredentialSpec: os.Getenv("SERVICE_A_CREDENTIAL_SPEC"),
+		OTLPEndpoint:           os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"),
+		OTELServiceName:        "service-b",
 	}
 
 	if config.Port == "" {
@@ -294,15 +456,45 @@ func main() {
 		)
 	}
 
-	if config.ServiceAAuthKey == "" {
-		config.ServiceAAuthKey = "default-secret-key"
-		// Log security warning about default auth key
-		slog.Warn("No authentication key provided, using default. This is NOT recommended for production!",
-			slog.String("default_key", maskAuthKey(config.ServiceAAuthKey)),
+	if config.ServiceACredentialSpec == "" {
+		config.ServiceACredentialSpec = "static://default-secret-key"
+		// Log security warning about default credential backend
+		slog.Warn("No Service A credential spec provided, using default static backend. This is NOT recommended for production!",
+			slog.String("credential_scheme", credentialScheme(config.ServiceACredentialSpec)),
 		)
 	}
 
-	server := NewServer(config)
+	if config.OTLPEndpoint == "" {
+		config.OTLPEndpoint = "http://localhost:4318"
+		slog.Info("No OTLP endpoint specified, using default",
+			slog.String("default_otlp_endpoint", config.OTLPEndpoint),
+		)
+	}
+
+	config.RetryMax = envInt("RETRY_MAX", 3)
+	config.BreakerThreshold = envFloat("BREAKER_THRESHOLD", 0.5)
+	config.BreakerOpenDuration = envDuration("BREAKER_OPEN_DURATION", 30*time.Second)
+	config.BreakerHalfOpenProbes = envInt("BREAKER_HALF_OPEN_PROBES", 1)
+	config.BulkheadSize = envInt("BULKHEAD_SIZE", 10)
+	config.ProcessTimeout = envDuration("PROCESS_TIMEOUT", 8*time.Second)
+
+	config.LogFile = os.Getenv("LOG_FILE")
+	config.LogMaxSizeMB = envInt("LOG_MAX_SIZE_MB", 100)
+	config.LogMaxBackups = envInt("LOG_MAX_BACKUPS", 3)
+	config.LogMaxAgeDays = envInt("LOG_MAX_AGE_DAYS", 28)
+	config.LogCompress = envBool("LOG_COMPRESS", false)
+
+	shutdownTracer, err := initTracer(context.Background(), config)
+	if err != nil {
+		slog.Error("Failed to initialize tracing", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	server, err := NewServer(config)
+	if err != nil {
+		slog.Error("Failed to initialize server", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
 
 	// Graceful shutdown
 	stop := make(chan os.Signal, 1)
@@ -320,9 +512,13 @@ func main() {
 
 	<-stop
 
+	// Flip readiness to failing immediately so Kubernetes stops routing new
+	// traffic before the listener is actually closed below.
+	server.shuttingDown.Store(true)
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	ctx = context.WithValue(ctx, "timeout", "10s")
+	ctx = context.WithValue(ctx, shutdownTimeoutKey, "10s")
 
 	if err := server.Shutdown(ctx); err != nil {
 		server.logger.Error("Server shutdown error",
@@ -331,5 +527,9 @@ func main() {
 		)
 	}
 
+	if err := shutdownTracer(ctx); err != nil {
+		server.logger.Error("Tracer shutdown error", slog.String("error", err.Error()))
+	}
+
 	server.logger.Info("Service B shutdown complete")
 }
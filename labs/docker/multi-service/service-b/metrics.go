@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrics holds the RED (rate, errors, duration) instruments for inbound
+// requests plus the outbound instruments for calls to Service A, all
+// exposed on /metrics. Registering against prometheus.DefaultRegisterer
+// also pulls in the Go runtime and process collectors client_golang
+// registers there by default.
+type Metrics struct {
+	requestsTotal    *prometheus.CounterVec
+	requestDuration  *prometheus.HistogramVec
+	inflightRequests prometheus.Gauge
+
+	outboundRequestsTotal   *prometheus.CounterVec
+	outboundRequestDuration *prometheus.HistogramVec
+
+	breakerTransitionsTotal *prometheus.CounterVec
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		requestsTotal: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Total number of HTTP requests processed, labeled by service, path, method and status code.",
+		}, []string{"service", "path", "method", "code"}),
+		requestDuration: promauto.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latency in seconds.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"service", "path", "method"}),
+		inflightRequests: promauto.NewGauge(prometheus.GaugeOpts{
+			Name: "http_inflight_requests",
+			Help: "Number of HTTP requests currently being served.",
+		}),
+		outboundRequestsTotal: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "outbound_requests_total",
+			Help: "Total number of outbound requests made to downstream services, labeled by target and status code.",
+		}, []string{"target", "code"}),
+		outboundRequestDuration: promauto.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "outbound_request_duration_seconds",
+			Help:    "Outbound request latency in seconds, labeled by target.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"target"}),
+		breakerTransitionsTotal: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "breaker_transitions_total",
+			Help: "Total number of circuit breaker state transitions, labeled by the from and to state.",
+		}, []string{"from", "to"}),
+	}
+}
+
+// Wrap instruments next with RED metrics for the given service/path labels,
+// without the handler itself needing to know metrics exist.
+func (m *Metrics) Wrap(service, path string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		m.inflightRequests.Inc()
+		defer m.inflightRequests.Dec()
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next(rec, r)
+
+		m.requestDuration.WithLabelValues(service, path, r.Method).Observe(time.Since(start).Seconds())
+		m.requestsTotal.WithLabelValues(service, path, r.Method, strconv.Itoa(rec.status)).Inc()
+	}
+}
+
+// ObserveOutbound records the duration and outcome of a call to target,
+// where code is "circuit_open" when the breaker failed the call fast and
+// "error" when the call otherwise produced no response.
+func (m *Metrics) ObserveOutbound(target string, duration time.Duration, code string) {
+	m.outboundRequestDuration.WithLabelValues(target).Observe(duration.Seconds())
+	m.outboundRequestsTotal.WithLabelValues(target, code).Inc()
+}
+
+// ObserveBreakerTransition records a circuit breaker moving from one state
+// to another, so operators can alert on, e.g., a rising rate of
+// closed->open transitions.
+func (m *Metrics) ObserveBreakerTransition(from, to string) {
+	m.breakerTransitionsTotal.WithLabelValues(from, to).Inc()
+}
+
+// statusRecorder captures the status code written by a handler so it can be
+// added to metrics after ServeHTTP returns.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
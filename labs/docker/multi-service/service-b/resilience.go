@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by Resilience.Do when the circuit breaker is
+// open and the call was failed fast without touching the network.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// breakerState is the state of a CircuitBreaker.
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerClosed:
+		return "closed"
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// breakerWindow is the number of closed-state calls counted before the
+// failure ratio is evaluated, so a single early failure doesn't open it.
+// The counts are reset after every evaluation.
+const breakerWindow = 10
+
+// CircuitBreaker trips to open once the failure ratio over a window of
+// breakerWindow closed-state calls reaches threshold (windows follow one
+// another without overlap; they do not slide), fails every call fast while
+// open, then admits up to halfOpenProbes probes. The first probe outcome
+// recorded closes it again on success or reopens it on failure.
+type CircuitBreaker struct {
+	mu             sync.Mutex
+	logger         *slog.Logger
+	metrics        *Metrics
+	threshold      float64
+	openDuration   time.Duration
+	halfOpenProbes int
+
+	state        breakerState
+	openedAt     time.Time
+	successes    int
+	failures     int
+	halfOpenLeft int
+}
+
+func NewCircuitBreaker(logger *slog.Logger, metrics *Metrics, threshold float64, openDuration time.Duration, halfOpenProbes int) *CircuitBreaker {
+	return &CircuitBreaker{
+		logger:         logger,
+		metrics:        metrics,
+		threshold:      threshold,
+		openDuration:   openDuration,
+		halfOpenProbes: halfOpenProbes,
+		state:          breakerClosed,
+	}
+}
+
+// Allow reports whether a call may proceed, transitioning open to half-open
+// once openDuration has elapsed. Every admitted call must be followed by a
+// matching Record.
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.openDuration {
+			return false
+		}
+		b.transition(breakerHalfOpen)
+		b.halfOpenLeft = b.halfOpenProbes
+		fallthrough
+	case breakerHalfOpen:
+		if b.halfOpenLeft <= 0 {
+			return false
+		}
+		b.halfOpenLeft--
+		return true
+	default:
+		return true
+	}
+}
+
+// Record reports the outcome of a call that Allow previously admitted.
+func (b *CircuitBreaker) Record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == breakerHalfOpen {
+		if success {
+			b.transition(breakerClosed)
+			b.successes, b.failures = 0, 0
+		} else {
+			b.transition(breakerOpen)
+			b.openedAt = time.Now()
+		}
+		return
+	}
+
+	if success {
+		b.successes++
+	} else {
+		b.failures++
+	}
+
+	if total := b.successes + b.failures; total >= breakerWindow {
+		if float64(b.failures)/float64(total) >= b.threshold {
+			b.transition(breakerOpen)
+			b.openedAt = time.Now()
+		}
+		b.successes, b.failures = 0, 0
+	}
+}
+
+// transition must be called with mu held. It logs the transition as a
+// structured event and increments breakerTransitionsTotal so alerts can be
+// built on top of it.
+func (b *CircuitBreaker) transition(to breakerState) {
+	if b.state == to {
+		return
+	}
+	from := b.state
+	b.state = to
+	b.logger.Info("Circuit breaker state transition",
+		slog.String("from", from.String()),
+		slog.String("to", to.String()),
+	)
+	b.metrics.ObserveBreakerTransition(from.String(), to.String())
+}
+
+// Bulkhead is a fixed-size concurrency limiter for outbound calls, so a slow
+// or failing downstream can't exhaust every goroutine in the process.
+type Bulkhead struct {
+	tokens chan struct{}
+}
+
+func NewBulkhead(size int) *Bulkhead {
+	return &Bulkhead{tokens: make(chan struct{}, size)}
+}
+
+// Acquire blocks until a slot is free or ctx is done.
+func (b *Bulkhead) Acquire(ctx context.Context) error {
+	select {
+	case b.tokens <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *Bulkhead) Release() {
+	<-b.tokens
+}
+
+// outboundTarget is the label value used for every metric and log event
+// emitted by Resilience, since Service B currently only calls Service A
+// through this wrapper.
+const outboundTarget = "service_a"
+
+// Resilience wraps an http.Client's outbound calls with a bulkhead, a
+// circuit breaker, and retry with exponential backoff and full jitter for
+// idempotent methods against transient errors and 5xx responses.
+type Resilience struct {
+	client   *http.Client
+	logger   *slog.Logger
+	metrics  *Metrics
+	breaker  *CircuitBreaker
+	bulkhead *Bulkhead
+	retryMax int
+}
+
+func NewResilience(client *http.Client, logger *slog.Logger, metrics *Metrics, cfg *Config) *Resilience {
+	return &Resilience{
+		client:   client,
+		logger:   logger,
+		metrics:  metrics,
+		breaker:  NewCircuitBreaker(logger, metrics, cfg.BreakerThreshold, cfg.BreakerOpenDuration, cfg.BreakerHalfOpenProbes),
+		bulkhead: NewBulkhead(cfg.BulkheadSize),
+		retryMax: cfg.RetryMax,
+	}
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
+
+func isRetryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode >= 500
+}
+
+// Do executes req through the bulkhead and circuit breaker, retrying
+// idempotent requests that fail with a transient error or 5xx response using
+// exponential backoff with full jitter. Non-idempotent methods are never
+// retried.
+func (r *Resilience) Do(req *http.Request) (*http.Response, error) {
+	if err := r.bulkhead.Acquire(req.Context()); err != nil {
+		return nil, fmt.Errorf("acquiring bulkhead slot: %w", err)
+	}
+	defer r.bulkhead.Release()
+
+	if !r.breaker.Allow() {
+		r.logger.Warn("Circuit breaker open, failing fast without calling Service A",
+			slog.String("url", req.URL.String()),
+		)
+		r.metrics.ObserveOutbound(outboundTarget, 0, "circuit_open")
+		return nil, ErrCircuitOpen
+	}
+
+	maxAttempts := 1
+	if isIdempotent(req.Method) {
+		maxAttempts += r.retryMax
+	}
+
+	start := time.Now()
+	var resp *http.Response
+	var err error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			backoff := fullJitterBackoff(attempt)
+			r.logger.Info("Retrying outbound request to Service A",
+				slog.String("url", req.URL.String()),
+				slog.Int("attempt", attempt+1),
+				slog.Duration("backoff", backoff),
+			)
+			select {
+			case <-time.After(backoff):
+			case <-req.Context().Done():
+				r.breaker.Record(false)
+				return nil, req.Context().Err()
+			}
+		}
+
+		resp, err = r.client.Do(req)
+		if !isRetryable(resp, err) {
+			break
+		}
+		if resp != nil && attempt < maxAttempts-1 {
+			resp.Body.Close()
+		}
+	}
+
+	r.breaker.Record(err == nil && resp != nil && resp.StatusCode < http.StatusInternalServerError)
+
+	code := "error"
+	if resp != nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	r.metrics.ObserveOutbound(outboundTarget, time.Since(start), code)
+
+	return resp, err
+}
+
+// fullJitterBackoff returns a random duration in [0, min(cap, base*2^(attempt-1))],
+// per the "full jitter" strategy from AWS's exponential backoff guidance.
+func fullJitterBackoff(attempt int) time.Duration {
+	const base = 100 * time.Millisecond
+	const max = 5 * time.Second
+
+	backoff := base << (attempt - 1)
+	if backoff <= 0 || backoff > max {
+		backoff = max
+	}
+	return time.Duration(rand.Int63n(int64(backoff) + 1))
+}